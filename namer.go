@@ -5,7 +5,15 @@
 package sqlexp
 
 import (
+	"context"
+	"database/sql"
 	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 const (
@@ -37,3 +45,158 @@ func NamerFromDriver(d driver.Driver) DriverNamer {
 	dn, _ := d.(DriverNamer)
 	return dn
 }
+
+// driverDialectRegistry maps the reflect type name of a driver.Driver to
+// the dialect it speaks, used by NamerFromDB as a last resort once every
+// version probe has failed.
+var driverDialectRegistry = map[string]string{
+	"*mssql.MssqlDriver":    DialectTSQL,
+	"*pq.Driver":            DialectPostgres,
+	"*stdlib.Driver":        DialectPostgres,
+	"mysql.MySQLDriver":     DialectMySQL,
+	"*sqlite3.SQLiteDriver": DialectSQLite,
+	"*godror.drv":           DialectOracle,
+}
+
+func dialectFromDriverType(d driver.Driver) (string, bool) {
+	dialect, ok := driverDialectRegistry[reflect.TypeOf(d).String()]
+	return dialect, ok
+}
+
+// probedNamer is the DriverNamer returned by NamerFromDB once it has
+// identified the database by probing it, rather than by the driver
+// implementing DriverNamer itself.
+type probedNamer struct {
+	name    string
+	dialect string
+}
+
+func (n *probedNamer) Name() string    { return n.name }
+func (n *probedNamer) Dialect() string { return n.dialect }
+
+var namerCache sync.Map // *sql.DB -> DriverNamer
+
+// versionProbe is a dialect-specific version query together with the
+// parser that turns its result into a canonical name.
+type versionProbe struct {
+	dialect string
+	query   string
+	parse   func(raw string) (product string, major, minor, patch int, ok bool)
+}
+
+var versionProbes = []versionProbe{
+	{DialectPostgres, "SELECT version()", parsePostgresVersion},
+	{DialectMySQL, "SELECT version()", parseMySQLVersion},
+	{DialectTSQL, "SELECT @@VERSION", parseTSQLVersion},
+	{DialectSQLite, "SELECT sqlite_version()", parseSQLiteVersion},
+	{DialectOracle, "SELECT banner FROM v$version", parseOracleVersion},
+}
+
+// NamerFromDB returns the DriverNamer for db. If db.Driver() implements
+// DriverNamer that is returned directly. Otherwise db is probed with
+// each dialect's version query in turn (SELECT version() for
+// Postgres/MySQL/CockroachDB, SELECT @@VERSION for SQL Server, SELECT
+// sqlite_version() for SQLite, SELECT banner FROM v$version for Oracle)
+// until one succeeds and parses into the canonical
+// "<product>-<major>.<minor>.<patch>" form documented on
+// DriverNamer.Name. If every probe fails, the dialect is guessed from
+// the reflect type of db.Driver(). The result is cached per *sql.DB.
+func NamerFromDB(ctx context.Context, db *sql.DB) (DriverNamer, error) {
+	if cached, ok := namerCache.Load(db); ok {
+		return cached.(DriverNamer), nil
+	}
+
+	if dn := NamerFromDriver(db.Driver()); dn != nil {
+		namerCache.Store(db, dn)
+		return dn, nil
+	}
+
+	for _, p := range versionProbes {
+		var raw string
+		if err := db.QueryRowContext(ctx, p.query).Scan(&raw); err != nil {
+			continue
+		}
+		product, major, minor, patch, ok := p.parse(raw)
+		if !ok {
+			continue
+		}
+		dn := &probedNamer{
+			name:    fmt.Sprintf("%s-%d.%d.%d", product, major, minor, patch),
+			dialect: p.dialect,
+		}
+		namerCache.Store(db, dn)
+		return dn, nil
+	}
+
+	if dialect, ok := dialectFromDriverType(db.Driver()); ok {
+		dn := &probedNamer{name: dialect, dialect: dialect}
+		namerCache.Store(db, dn)
+		return dn, nil
+	}
+
+	return nil, fmt.Errorf("sqlexp: unable to determine database dialect for %T", db.Driver())
+}
+
+func atoiOrZero(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+var (
+	cockroachVersionRe = regexp.MustCompile(`CockroachDB CCL v(\d+)\.(\d+)\.(\d+)`)
+	postgresVersionRe  = regexp.MustCompile(`PostgreSQL (\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+func parsePostgresVersion(raw string) (string, int, int, int, bool) {
+	if m := cockroachVersionRe.FindStringSubmatch(raw); m != nil {
+		return "cockroachdb", atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+	}
+	if m := postgresVersionRe.FindStringSubmatch(raw); m != nil {
+		return "postgresql", atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+	}
+	return "", 0, 0, 0, false
+}
+
+var mysqlVersionRe = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+func parseMySQLVersion(raw string) (string, int, int, int, bool) {
+	m := mysqlVersionRe.FindStringSubmatch(raw)
+	if m == nil {
+		return "", 0, 0, 0, false
+	}
+	product := "mysql"
+	if strings.Contains(strings.ToLower(raw), "mariadb") {
+		product = "mariadb"
+	}
+	return product, atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+}
+
+var tsqlVersionRe = regexp.MustCompile(`(?s)Microsoft SQL Server.*?(\d+)\.(\d+)\.(\d+)`)
+
+func parseTSQLVersion(raw string) (string, int, int, int, bool) {
+	m := tsqlVersionRe.FindStringSubmatch(raw)
+	if m == nil {
+		return "", 0, 0, 0, false
+	}
+	return "sqlserver", atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+}
+
+var sqliteVersionRe = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+func parseSQLiteVersion(raw string) (string, int, int, int, bool) {
+	m := sqliteVersionRe.FindStringSubmatch(raw)
+	if m == nil {
+		return "", 0, 0, 0, false
+	}
+	return "sqlite", atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+}
+
+var oracleVersionRe = regexp.MustCompile(`Release (\d+)\.(\d+)\.(\d+)`)
+
+func parseOracleVersion(raw string) (string, int, int, int, bool) {
+	m := oracleVersionRe.FindStringSubmatch(raw)
+	if m == nil {
+		return "", 0, 0, 0, false
+	}
+	return "oracle", atoiOrZero(m[1]), atoiOrZero(m[2]), atoiOrZero(m[3]), true
+}
@@ -0,0 +1,58 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlexp
+
+import "testing"
+
+func TestParsePostgresVersion(t *testing.T) {
+	product, major, minor, patch, ok := parsePostgresVersion(
+		"PostgreSQL 13.4 on x86_64-pc-linux-gnu, compiled by gcc (GCC) 4.8.5, 64-bit")
+	if !ok || product != "postgresql" || major != 13 || minor != 4 || patch != 0 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+}
+
+func TestParseCockroachVersion(t *testing.T) {
+	product, major, minor, patch, ok := parsePostgresVersion(
+		"CockroachDB CCL v21.2.3 (x86_64-pc-linux-gnu, built 2021/12/06 18:24:03, go1.16.6)")
+	if !ok || product != "cockroachdb" || major != 21 || minor != 2 || patch != 3 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+}
+
+func TestParseMySQLVersion(t *testing.T) {
+	product, major, minor, patch, ok := parseMySQLVersion("8.0.26")
+	if !ok || product != "mysql" || major != 8 || minor != 0 || patch != 26 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+
+	product, _, _, _, ok = parseMySQLVersion("10.5.9-MariaDB")
+	if !ok || product != "mariadb" {
+		t.Errorf("got %s ok=%v, want mariadb", product, ok)
+	}
+}
+
+func TestParseTSQLVersion(t *testing.T) {
+	product, major, minor, patch, ok := parseTSQLVersion(
+		"Microsoft SQL Server 2019 (RTM) - 15.0.2000.5 (X64) \n\tSep 24 2019 13:48:23")
+	if !ok || product != "sqlserver" || major != 15 || minor != 0 || patch != 2000 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+}
+
+func TestParseSQLiteVersion(t *testing.T) {
+	product, major, minor, patch, ok := parseSQLiteVersion("3.36.0")
+	if !ok || product != "sqlite" || major != 3 || minor != 36 || patch != 0 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+}
+
+func TestParseOracleVersion(t *testing.T) {
+	product, major, minor, patch, ok := parseOracleVersion(
+		"Oracle Database 19c Enterprise Edition Release 19.3.0.0.0 - Production")
+	if !ok || product != "oracle" || major != 19 || minor != 3 || patch != 0 {
+		t.Errorf("got %s-%d.%d.%d ok=%v", product, major, minor, patch, ok)
+	}
+}
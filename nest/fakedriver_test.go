@@ -0,0 +1,170 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a minimal in-memory database/sql/driver used to exercise
+// nest against a real *sql.DB without a live database. It records every
+// statement it is asked to run and can be told to fail a given query a
+// fixed number of times before succeeding, to drive retry tests.
+type fakeDriver struct {
+	mu       sync.Mutex
+	execLog  []string
+	failExec map[string]int
+}
+
+func newFakeDB() (*sql.DB, *fakeDriver) {
+	d := &fakeDriver{failExec: map[string]int{}}
+	return sql.OpenDB(fakeConnector{d: d}), d
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) logged() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]string, len(d.execLog))
+	copy(out, d.execLog)
+	return out
+}
+
+// failNext arranges for the next n calls whose query equals query to
+// return a retryable error instead of succeeding.
+func (d *fakeDriver) failNext(query string, n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.failExec[query] = n
+}
+
+// run executes query against the fake database. It rejects any query
+// text containing more than one statement, the way lib/pq, pgx/stdlib,
+// and go-sql-driver/mysql (without multiStatements=true) do, so a test
+// that tries to batch a SET ahead of a query in one call fails loudly
+// instead of silently "working" only against this fake.
+func (d *fakeDriver) run(query string) (driver.Rows, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if strings.Contains(query, ";") {
+		return nil, fakeMultiStatementError{}
+	}
+	// The dialect probe NamerFromDB issues ahead of the first query on a
+	// *sql.DB is plumbing, not a statement a test should have to account
+	// for, so it isn't recorded in execLog.
+	if query == "SELECT version()" {
+		return &fakeRows{cols: []string{"version"}, rows: [][]driver.Value{
+			{"PostgreSQL 13.4 on x86_64-pc-linux-gnu"},
+		}}, nil
+	}
+	d.execLog = append(d.execLog, query)
+	if n := d.failExec[query]; n > 0 {
+		d.failExec[query] = n - 1
+		return nil, fakeRetryableError{}
+	}
+	return &fakeRows{cols: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}, nil
+}
+
+type fakeConnector struct{ d *fakeDriver }
+
+func (c fakeConnector) Connect(ctx context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c fakeConnector) Driver() driver.Driver                            { return c.d }
+
+type fakeConn struct{ d *fakeDriver }
+
+var (
+	_ driver.Conn           = (*fakeConn)(nil)
+	_ driver.ExecerContext  = (*fakeConn)(nil)
+	_ driver.QueryerContext = (*fakeConn)(nil)
+	_ driver.ConnBeginTx    = (*fakeConn)(nil)
+)
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.d.run(query); err != nil {
+		return nil, err
+	}
+	return fakeResult{}, nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.d.run(query)
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := s.conn.d.run(s.query); err != nil {
+		return nil, err
+	}
+	return fakeResult{}, nil
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.d.run(s.query)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
+
+// fakeRetryableError mimics a Postgres serialization failure, the
+// driver-agnostic shape postgresRetryClassifier recognizes.
+type fakeRetryableError struct{}
+
+func (fakeRetryableError) Error() string    { return "fakedriver: serialization failure" }
+func (fakeRetryableError) SQLState() string { return "40001" }
+
+// fakeMultiStatementError mimics the rejection a single-statement-only
+// driver returns for query text containing more than one command.
+type fakeMultiStatementError struct{}
+
+func (fakeMultiStatementError) Error() string {
+	return "fakedriver: cannot insert multiple commands into a prepared statement"
+}
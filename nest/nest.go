@@ -12,6 +12,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/golang-sql/sqlexp"
 )
@@ -44,8 +45,66 @@ var (
 	_ Querier = &Conn{}
 )
 
+// SessionVarHook renders the session variables carried on a context into a
+// dialect-correct SQL statement (e.g. "SET LOCAL app.tenant_id = 'x'", or
+// "EXEC sp_set_session_context 'app.tenant_id', 'x'" for T-SQL) to be run
+// before the next statement. It returns "" to apply nothing.
+type SessionVarHook func(dialect string, kv map[string]string) string
+
 type DB struct {
 	db *sql.DB
+
+	sessionVarMu   sync.RWMutex
+	sessionVarHook SessionVarHook
+
+	retryMu     sync.RWMutex
+	retryPolicy RetryPolicy
+}
+
+// SetSessionVarHook installs the hook used to turn the session variables
+// carried on a context (see WithSessionVar) into SQL that is run ahead of
+// a statement issued through a Tx or Conn obtained from this DB whenever
+// those variables differ from the ones last applied to it. This
+// lets applications implement row-level security, multi-tenancy, or
+// audit-user tracking without threading that state through each query
+// site by hand.
+//
+// The hook has no effect on DB's own ExecContext/QueryContext/
+// QueryRowContext/PrepareContext: *sql.DB hands each call an arbitrary
+// pooled connection, so there is no single connection a DB-level SET
+// could be pinned to. Prepending it to the query text as one batched
+// call doesn't work either -- lib/pq, pgx/stdlib, and go-sql-driver/mysql
+// (without multiStatements=true) all reject multiple commands in one
+// statement -- and borrowing a dedicated connection for the call and
+// closing it before the caller can drain the returned Rows/Row/Stmt
+// deadlocks. A Tx or Conn already pins one connection for its lifetime,
+// so call BeginTx or Conn to get session-scoped queries.
+func (db *DB) SetSessionVarHook(hook SessionVarHook) {
+	db.sessionVarMu.Lock()
+	defer db.sessionVarMu.Unlock()
+	db.sessionVarHook = hook
+}
+
+func (db *DB) hook() SessionVarHook {
+	db.sessionVarMu.RLock()
+	defer db.sessionVarMu.RUnlock()
+	return db.sessionVarHook
+}
+
+// dialect returns the SQL dialect of the wrapped *sql.DB by probing it
+// through sqlexp.NamerFromDB, falling back to a direct NamerFromDriver
+// type assertion if the probe itself fails (e.g. ctx is already done).
+// Virtually no real-world driver implements DriverNamer, so relying on
+// NamerFromDriver alone always returned "" and meant the dialect-keyed
+// SessionVarHook and RunInTx retry classification never actually fired.
+func (db *DB) dialect(ctx context.Context) string {
+	if dn, err := sqlexp.NamerFromDB(ctx, db.db); err == nil {
+		return dn.Dialect()
+	}
+	if dn := sqlexp.NamerFromDriver(db.db.Driver()); dn != nil {
+		return dn.Dialect()
+	}
+	return ""
 }
 
 func (db *DB) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
@@ -53,9 +112,16 @@ func (db *DB) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{ctx: ctx, tx: tx, db: db, savepointer: savepointFromDriver(db.db.Driver())}, nil
+	t := &Tx{ctx: ctx, tx: tx, db: db, savepointer: savepointFromDriver(db.db.Driver()), shared: &txShared{}}
+	if err := t.applySessionVars(ctx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return t, nil
 }
 
+// ExecContext runs query on the pooled *sql.DB. See SetSessionVarHook for
+// why ctx's session variables, if any, are not applied here.
 func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
 	return db.db.ExecContext(ctx, query, args...)
 }
@@ -64,18 +130,36 @@ func (db *DB) PingContext(ctx context.Context) error {
 	return db.db.PingContext(ctx)
 }
 
+// PrepareContext prepares query on the pooled *sql.DB. See
+// SetSessionVarHook for why ctx's session variables, if any, are not
+// applied here; a *Stmt outliving the context that prepared it is a
+// second, independent reason this could never have worked at prepare
+// time regardless.
 func (db *DB) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
 	return db.db.PrepareContext(ctx, query)
 }
 
+// QueryContext runs query on the pooled *sql.DB. See SetSessionVarHook
+// for why ctx's session variables, if any, are not applied here.
 func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
 	return db.db.QueryContext(ctx, query, args...)
 }
 
+// QueryRowContext runs query on the pooled *sql.DB. See SetSessionVarHook
+// for why ctx's session variables, if any, are not applied here.
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
 	return db.db.QueryRowContext(ctx, query, args...)
 }
 
+// Conn returns a dedicated, nestable connection to the database.
+func (db *DB) Conn(ctx context.Context) (*Conn, error) {
+	c, err := db.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{db: db, conn: c}, nil
+}
+
 var errNoTx = errors.New("sqlexp/nest: not in a transaction")
 var errNoNested = errors.New("sqlexp/nest: nested transactions not supported")
 
@@ -99,6 +183,16 @@ type Tx struct {
 
 	savepointer sqlexp.Savepointer
 	savepoint   string // Empty if not in a snapshot, otherwise the name of the snapshot.
+
+	// shared is common to every Tx wrapping the same underlying *sql.Tx,
+	// so that session variables are applied to it only once no matter
+	// how many savepoint-nested Tx's are layered on top.
+	shared *txShared
+}
+
+type txShared struct {
+	mu      sync.Mutex
+	applied map[string]string // session vars already applied to this *sql.Tx
 }
 
 func savepointFromDriver(d driver.Driver) sqlexp.Savepointer {
@@ -106,6 +200,38 @@ func savepointFromDriver(d driver.Driver) sqlexp.Savepointer {
 	return sp
 }
 
+// applySessionVars runs the DB's session var hook against this Tx's
+// underlying *sql.Tx, but only if the variables carried on ctx differ
+// from the ones last applied to it.
+func (tx *Tx) applySessionVars(ctx context.Context) error {
+	if tx.db == nil {
+		return nil
+	}
+	hook := tx.db.hook()
+	if hook == nil {
+		return nil
+	}
+	kv := sessionVarsFromContext(ctx)
+	if len(kv) == 0 {
+		return nil
+	}
+
+	tx.shared.mu.Lock()
+	defer tx.shared.mu.Unlock()
+	if sessionVarsEqual(tx.shared.applied, kv) {
+		return nil
+	}
+	set := hook(tx.db.dialect(ctx), kv)
+	if set == "" {
+		return nil
+	}
+	if _, err := tx.tx.ExecContext(ctx, set); err != nil {
+		return err
+	}
+	tx.shared.applied = kv
+	return nil
+}
+
 func (tx *Tx) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
 	if tx.savepointer == nil {
 		return nil, errNoNested
@@ -117,10 +243,25 @@ func (tx *Tx) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{ctx: ctx, tx: tx.tx, savepoint: savepoint, index: index}, nil
+	nested := &Tx{
+		ctx:         ctx,
+		db:          tx.db,
+		tx:          tx.tx,
+		savepoint:   savepoint,
+		index:       index,
+		savepointer: tx.savepointer,
+		shared:      tx.shared,
+	}
+	if err := nested.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
+	return nested, nil
 }
 
 func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	if err := tx.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return tx.tx.ExecContext(ctx, query, args...)
 }
 
@@ -129,12 +270,19 @@ func (tx *Tx) PingContext(ctx context.Context) error {
 }
 
 func (tx *Tx) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	if err := tx.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return tx.tx.PrepareContext(ctx, query)
 }
 func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	if err := tx.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return tx.tx.QueryContext(ctx, query, args...)
 }
 func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	tx.applySessionVars(ctx)
 	return tx.tx.QueryRowContext(ctx, query, args)
 }
 
@@ -166,17 +314,66 @@ func (tx *Tx) Tx() *sql.Tx {
 type Conn struct {
 	db   *DB
 	conn *sql.Conn
+
+	mu      sync.Mutex
+	applied map[string]string // session vars already applied to this Conn
+}
+
+// Reset clears the session variables already applied to this Conn, so
+// that they are reapplied on its next query. Call Reset when checking a
+// Conn back in to an application-level connection pool for reuse by a
+// different logical session.
+func (c *Conn) Reset() error {
+	c.mu.Lock()
+	c.applied = nil
+	c.mu.Unlock()
+	return nil
+}
+
+// applySessionVars runs the DB's session var hook against this Conn, but
+// only if the variables carried on ctx differ from the ones last applied
+// to it.
+func (c *Conn) applySessionVars(ctx context.Context) error {
+	hook := c.db.hook()
+	if hook == nil {
+		return nil
+	}
+	kv := sessionVarsFromContext(ctx)
+	if len(kv) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sessionVarsEqual(c.applied, kv) {
+		return nil
+	}
+	set := hook(c.db.dialect(ctx), kv)
+	if set == "" {
+		return nil
+	}
+	if _, err := c.conn.ExecContext(ctx, set); err != nil {
+		return err
+	}
+	c.applied = kv
+	return nil
 }
 
 func (c *Conn) BeginTx(ctx context.Context, opts *TxOptions) (*Tx, error) {
+	if err := c.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	tx, err := c.conn.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{ctx: ctx, tx: tx, db: c.db, savepointer: savepointFromDriver(c.db.db.Driver())}, nil
+	return &Tx{ctx: ctx, tx: tx, db: c.db, savepointer: savepointFromDriver(c.db.db.Driver()), shared: &txShared{}}, nil
 }
 
 func (c *Conn) ExecContext(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	if err := c.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return c.conn.ExecContext(ctx, query, args...)
 }
 
@@ -185,14 +382,21 @@ func (c *Conn) PingContext(ctx context.Context) error {
 }
 
 func (c *Conn) PrepareContext(ctx context.Context, query string) (*Stmt, error) {
+	if err := c.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return c.conn.PrepareContext(ctx, query)
 }
 
 func (c *Conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	if err := c.applySessionVars(ctx); err != nil {
+		return nil, err
+	}
 	return c.conn.QueryContext(ctx, query, args...)
 }
 
 func (c *Conn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *Row {
+	c.applySessionVars(ctx)
 	return c.conn.QueryRowContext(ctx, query, args...)
 }
 
@@ -0,0 +1,124 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/golang-sql/sqlexp"
+)
+
+// RetryClassifier decides whether an error returned from inside a
+// RunInTx function body indicates a transient failure (serialization
+// failure, deadlock) that is safe to retry.
+type RetryClassifier interface {
+	ShouldRetry(err error) bool
+}
+
+var (
+	classifierMu sync.RWMutex
+
+	// classifierRegistry maps a sqlexp dialect constant to the
+	// RetryClassifier that knows how to recognize that dialect's
+	// transient-failure codes.
+	classifierRegistry = map[string]RetryClassifier{
+		sqlexp.DialectPostgres: postgresRetryClassifier{},
+		sqlexp.DialectMySQL:    mysqlRetryClassifier{},
+		sqlexp.DialectTSQL:     tsqlRetryClassifier{},
+	}
+)
+
+// RegisterRetryClassifier registers the RetryClassifier used by RunInTx
+// for the given dialect, overriding the built-in one if any.
+func RegisterRetryClassifier(dialect string, c RetryClassifier) {
+	classifierMu.Lock()
+	defer classifierMu.Unlock()
+	classifierRegistry[dialect] = c
+}
+
+func retryClassifierForDialect(dialect string) RetryClassifier {
+	classifierMu.RLock()
+	defer classifierMu.RUnlock()
+	return classifierRegistry[dialect]
+}
+
+// sqlState extracts a Postgres SQLSTATE from err, without this package
+// importing any Postgres driver. It first tries the de facto SQLState()
+// string method (implemented by pgx's pgconn.PgError), then falls back
+// to reflecting out a string-typed Code field (as exposed by lib/pq's
+// pq.Error).
+func sqlState(err error) string {
+	if s, ok := err.(interface{ SQLState() string }); ok {
+		return s.SQLState()
+	}
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	f := v.FieldByName("Code")
+	if !f.IsValid() || f.Kind() != reflect.String {
+		return ""
+	}
+	return f.String()
+}
+
+// driverErrorNumber reflects out an integer-typed Number field, as
+// exposed by go-sql-driver/mysql's *mysql.MySQLError and
+// denisenkom/go-mssqldb's mssql.Error, without this package importing
+// either driver.
+func driverErrorNumber(err error) (int64, bool) {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	f := v.FieldByName("Number")
+	if !f.IsValid() {
+		return 0, false
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint()), true
+	}
+	return 0, false
+}
+
+// postgresRetryClassifier retries Postgres serialization failures (40001)
+// and deadlocks (40P01), the two SQLSTATEs a serializable or
+// repeatable-read transaction is expected to handle by retrying.
+type postgresRetryClassifier struct{}
+
+func (postgresRetryClassifier) ShouldRetry(err error) bool {
+	switch sqlState(err) {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// mysqlRetryClassifier retries MySQL error 1213, ER_LOCK_DEADLOCK.
+type mysqlRetryClassifier struct{}
+
+func (mysqlRetryClassifier) ShouldRetry(err error) bool {
+	n, ok := driverErrorNumber(err)
+	return ok && n == 1213
+}
+
+// tsqlRetryClassifier retries SQL Server error 1205, the deadlock victim
+// error.
+type tsqlRetryClassifier struct{}
+
+func (tsqlRetryClassifier) ShouldRetry(err error) bool {
+	n, ok := driverErrorNumber(err)
+	return ok && n == 1205
+}
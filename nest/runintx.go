@@ -0,0 +1,197 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy configures the retry behavior of RunInTx. The zero value is
+// not usable directly; DefaultRetryPolicy is applied to any field left at
+// its zero value.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times fn is invoked, including
+	// the first attempt.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. Subsequent retries
+	// double it, capped at MaxDelay, then add up to 50% jitter.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff delay between retries.
+	MaxDelay time.Duration
+
+	// Classifier, if set, overrides the RetryClassifier that would
+	// otherwise be looked up by dialect via RegisterRetryClassifier.
+	Classifier RetryClassifier
+}
+
+// DefaultRetryPolicy is used by RunInTx until SetRetryPolicy is called.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    1 * time.Second,
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return p
+}
+
+// SetRetryPolicy installs the RetryPolicy used by RunInTx for every Tx,
+// Conn, and nested savepoint Tx begun from db.
+func (db *DB) SetRetryPolicy(p RetryPolicy) {
+	db.retryMu.Lock()
+	defer db.retryMu.Unlock()
+	db.retryPolicy = p
+}
+
+func (db *DB) getRetryPolicy() RetryPolicy {
+	db.retryMu.RLock()
+	defer db.retryMu.RUnlock()
+	return db.retryPolicy.withDefaults()
+}
+
+func (db *DB) classifierFor(ctx context.Context, policy RetryPolicy) RetryClassifier {
+	if policy.Classifier != nil {
+		return policy.Classifier
+	}
+	return retryClassifierForDialect(db.dialect(ctx))
+}
+
+// backoff blocks for the delay appropriate to attempt (1-based), or
+// returns ctx.Err() if ctx is done first.
+func backoff(ctx context.Context, policy RetryPolicy, attempt int) error {
+	delay := policy.BaseDelay << uint(attempt-1)
+	if delay <= 0 || delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// RunInTx runs fn in a transaction, committing if fn returns nil and
+// rolling back otherwise. If fn's error is recognized by the DB's
+// RetryClassifier as a transient failure (a Postgres serialization
+// failure, a MySQL or SQL Server deadlock, ...) the transaction is rolled
+// back and retried with exponential backoff up to the configured
+// RetryPolicy.MaxAttempts.
+//
+// When RunInTx is called on a Tx that is already inside a transaction,
+// the retry loop begins and rolls back a savepoint instead of the whole
+// transaction, so a failure deep in a nested call only rewinds its own
+// savepoint.
+func (db *DB) RunInTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context, q Querier) error) error {
+	policy := db.getRetryPolicy()
+	classifier := db.classifierFor(ctx, policy)
+
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if werr := backoff(ctx, policy, attempt-1); werr != nil {
+				return werr
+			}
+		}
+
+		tx, berr := db.BeginTx(ctx, opts)
+		if berr != nil {
+			return berr
+		}
+
+		err = fn(ctx, tx)
+		if err == nil {
+			return tx.Commit()
+		}
+		tx.Rollback()
+
+		if classifier == nil || !classifier.ShouldRetry(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// RunInTx behaves like (*DB).RunInTx, except the nested transaction is a
+// savepoint inside tx's underlying transaction, and a retry only rewinds
+// to that savepoint rather than to tx itself.
+func (tx *Tx) RunInTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context, q Querier) error) error {
+	policy := tx.db.getRetryPolicy()
+	classifier := tx.db.classifierFor(ctx, policy)
+
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if werr := backoff(ctx, policy, attempt-1); werr != nil {
+				return werr
+			}
+		}
+
+		nested, berr := tx.BeginTx(ctx, opts)
+		if berr != nil {
+			return berr
+		}
+
+		err = fn(ctx, nested)
+		if err == nil {
+			return nested.Commit()
+		}
+		nested.Rollback()
+
+		if classifier == nil || !classifier.ShouldRetry(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// RunInTx behaves like (*DB).RunInTx, but begins the transaction on this
+// dedicated Conn.
+func (c *Conn) RunInTx(ctx context.Context, opts *TxOptions, fn func(ctx context.Context, q Querier) error) error {
+	policy := c.db.getRetryPolicy()
+	classifier := c.db.classifierFor(ctx, policy)
+
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			if werr := backoff(ctx, policy, attempt-1); werr != nil {
+				return werr
+			}
+		}
+
+		tx, berr := c.BeginTx(ctx, opts)
+		if berr != nil {
+			return berr
+		}
+
+		err = fn(ctx, tx)
+		if err == nil {
+			return tx.Commit()
+		}
+		tx.Rollback()
+
+		if classifier == nil || !classifier.ShouldRetry(err) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunInTxRetriesOnClassifiedError(t *testing.T) {
+	sqlDB, fd := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	})
+
+	// Fail the insert twice with a Postgres serialization failure; dialect
+	// detection (via the "SELECT version()" probe the fake driver answers)
+	// must resolve to postgres for postgresRetryClassifier to recognize it
+	// and retry, rather than giving up on the first attempt.
+	fd.failNext("INSERT INTO widgets VALUES (1)", 2)
+
+	attempts := 0
+	err := db.RunInTx(context.Background(), nil, func(ctx context.Context, q Querier) error {
+		attempts++
+		_, err := q.ExecContext(ctx, "INSERT INTO widgets VALUES (1)")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RunInTx: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("got %d attempts, want 3", attempts)
+	}
+}
+
+func TestRunInTxGivesUpOnUnclassifiedError(t *testing.T) {
+	sqlDB, _ := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    time.Millisecond,
+	})
+
+	attempts := 0
+	err := db.RunInTx(context.Background(), nil, func(ctx context.Context, q Querier) error {
+		attempts++
+		return errUnclassified
+	})
+	if err != errUnclassified {
+		t.Fatalf("got err %v, want errUnclassified", err)
+	}
+	if attempts != 1 {
+		t.Fatalf("got %d attempts, want 1 (no retry for an unrecognized error)", attempts)
+	}
+}
+
+var errUnclassified = errNotRetryable{}
+
+type errNotRetryable struct{}
+
+func (errNotRetryable) Error() string { return "fakedriver: not a transient failure" }
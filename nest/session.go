@@ -0,0 +1,43 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import "context"
+
+type sessionVarsKey struct{}
+
+// WithSessionVar returns a context that carries key=value as a session
+// variable to be applied before the next statement executed through a
+// Querier obtained from a DB whose SetSessionVarHook has been set.
+//
+// Session variables accumulate: calling WithSessionVar again, including
+// with a context already carrying variables, layers the new key on top
+// of the existing ones rather than replacing them.
+func WithSessionVar(ctx context.Context, key, value string) context.Context {
+	vars := sessionVarsFromContext(ctx)
+	next := make(map[string]string, len(vars)+1)
+	for k, v := range vars {
+		next[k] = v
+	}
+	next[key] = value
+	return context.WithValue(ctx, sessionVarsKey{}, next)
+}
+
+func sessionVarsFromContext(ctx context.Context) map[string]string {
+	vars, _ := ctx.Value(sessionVarsKey{}).(map[string]string)
+	return vars
+}
+
+func sessionVarsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package nest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// withDeadline runs fn in a goroutine and fails the test if it doesn't
+// return within d, instead of hanging the whole suite if fn deadlocks.
+func withDeadline(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatal("timed out, likely deadlocked")
+	}
+}
+
+func sessionHook(dialect string, kv map[string]string) string {
+	return "SET app.tenant_id = '" + kv["tenant_id"] + "'"
+}
+
+// DB's own Exec/Query/QueryRow/PrepareContext run on an arbitrary pooled
+// connection, so SetSessionVarHook only takes effect through a Tx or
+// Conn (see their tests below). These guard that a session-carrying
+// context is still passed straight through unmodified rather than, as an
+// earlier version did, batched into the query text (which a real driver
+// would reject) or routed through a borrowed connection (which
+// deadlocked once the caller held the returned Rows/Row past this call's
+// return).
+func TestDBExecContextIgnoresSessionVars(t *testing.T) {
+	sqlDB, fd := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetSessionVarHook(sessionHook)
+
+	ctx := WithSessionVar(context.Background(), "tenant_id", "acme")
+	withDeadline(t, 2*time.Second, func() {
+		if _, err := db.ExecContext(ctx, "UPDATE widgets SET x = 1"); err != nil {
+			t.Fatalf("ExecContext: %v", err)
+		}
+	})
+
+	if log := fd.logged(); len(log) != 1 || log[0] != "UPDATE widgets SET x = 1" {
+		t.Fatalf("expected the query to run unmodified, got %v", log)
+	}
+}
+
+func TestDBQueryContextIgnoresSessionVars(t *testing.T) {
+	sqlDB, _ := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetSessionVarHook(sessionHook)
+
+	ctx := WithSessionVar(context.Background(), "tenant_id", "acme")
+	withDeadline(t, 2*time.Second, func() {
+		rows, err := db.QueryContext(ctx, "SELECT n FROM widgets")
+		if err != nil {
+			t.Fatalf("QueryContext: %v", err)
+		}
+		defer rows.Close()
+		if !rows.Next() {
+			t.Fatal("expected a row")
+		}
+	})
+}
+
+func TestDBQueryRowContextIgnoresSessionVars(t *testing.T) {
+	sqlDB, _ := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetSessionVarHook(sessionHook)
+
+	ctx := WithSessionVar(context.Background(), "tenant_id", "acme")
+	withDeadline(t, 2*time.Second, func() {
+		var n int64
+		if err := db.QueryRowContext(ctx, "SELECT n FROM widgets").Scan(&n); err != nil {
+			t.Fatalf("QueryRowContext.Scan: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("got n=%d, want 1", n)
+		}
+	})
+}
+
+// TestTxAppliesSessionVarsAsOwnStatement exercises the path
+// SetSessionVarHook is documented to affect: a Tx pins a single
+// connection for its lifetime, so the SET runs as its own statement
+// ahead of the first query rather than batched into it. fakeDriver.run
+// rejects any query containing more than one statement, so this would
+// fail the same way a real driver does if applySessionVars ever went
+// back to prepending the SET instead of running it separately.
+func TestTxAppliesSessionVarsAsOwnStatement(t *testing.T) {
+	sqlDB, fd := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetSessionVarHook(sessionHook)
+
+	ctx := WithSessionVar(context.Background(), "tenant_id", "acme")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE widgets SET x = 1"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	log := fd.logged()
+	if len(log) != 2 || log[0] != "SET app.tenant_id = 'acme'" || log[1] != "UPDATE widgets SET x = 1" {
+		t.Fatalf("expected the SET to run as its own statement ahead of the query, got %v", log)
+	}
+}
+
+// TestConnAppliesSessionVarsAsOwnStatement is the Conn equivalent of
+// TestTxAppliesSessionVarsAsOwnStatement: a Conn also pins a single
+// connection for its lifetime.
+func TestConnAppliesSessionVarsAsOwnStatement(t *testing.T) {
+	sqlDB, fd := newFakeDB()
+	defer sqlDB.Close()
+	db := Wrap(sqlDB)
+	db.SetSessionVarHook(sessionHook)
+
+	ctx := WithSessionVar(context.Background(), "tenant_id", "acme")
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "UPDATE widgets SET x = 1"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+
+	log := fd.logged()
+	if len(log) != 2 || log[0] != "SET app.tenant_id = 'acme'" || log[1] != "UPDATE widgets SET x = 1" {
+		t.Fatalf("expected the SET to run as its own statement ahead of the query, got %v", log)
+	}
+}
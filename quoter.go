@@ -5,9 +5,14 @@
 package sqlexp
 
 import (
+	"context"
 	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Quoter returns safe and valid SQL strings to use when building a SQL text.
@@ -17,6 +22,12 @@ type Quoter interface {
 	// it only operates on single identifiers such as "public" and "Table".
 	ID(name string) string
 
+	// QualifiedID quotes and joins a multipart identifier such as
+	// schema, table, column into a single dialect-correct string, each
+	// part quoted as by ID, for example "schema"."table"."column" for
+	// Postgres, Oracle, and SQLite or [schema].[table].[column] for T-SQL.
+	QualifiedID(parts ...string) string
+
 	// Value quotes database values such as string or []byte types as strings
 	// that are suitable and safe to embed in SQL text. The returned value
 	// of a string will include all surrounding quotes.
@@ -31,21 +42,110 @@ type DriverQuoter interface {
 	Quoter() Quoter
 }
 
-// FromDriver takes a database driver, often obtained through a sql.DB.Driver
-// call or from using it directly to get the quoter interface.
+var (
+	quoterMu sync.RWMutex
+
+	// quoterRegistry maps the reflect type name of a driver.Driver (as
+	// returned by reflect.TypeOf(d).String()) to the Quoter that
+	// understands its dialect. It is seeded with the common drivers for
+	// each built-in dialect so third-party drivers work without this
+	// package importing them.
+	quoterRegistry = map[string]Quoter{
+		"*mssql.MssqlDriver":    sqlServerQuoter{},
+		"*pq.Driver":            postgresQuoter{},
+		"*stdlib.Driver":        postgresQuoter{},
+		"mysql.MySQLDriver":     mysqlQuoter{},
+		"*sqlite3.SQLiteDriver": sqliteQuoter{},
+		"*godror.drv":           oracleQuoter{},
+	}
+)
+
+// RegisterQuoter registers a Quoter to be returned by QuoterFromDriver for
+// any driver.Driver whose reflect type name matches driverTypeName, for
+// example "*pgx.Driver". It is intended to be called from the init
+// function of a package that wants QuoterFromDriver to recognize a
+// third-party driver without sqlexp importing it directly.
+//
+// RegisterQuoter is safe to call concurrently with QuoterFromDriver; it
+// replaces any Quoter already registered for driverTypeName.
+func RegisterQuoter(driverTypeName string, q Quoter) {
+	quoterMu.Lock()
+	defer quoterMu.Unlock()
+	quoterRegistry[driverTypeName] = q
+}
+
+// QuoterFromDriver takes a database driver, often obtained through a
+// sql.DB.Driver call, and returns the Quoter for its SQL dialect.
 //
-// Currently MssqlDriver is hard-coded to also return a valided Quoter.
-func FromDriver(d driver.Driver) Quoter {
+// If the driver implements DriverQuoter that Quoter is returned directly.
+// Otherwise the driver's reflect type name is looked up in the registry
+// populated by RegisterQuoter, which already contains entries for the
+// common Postgres, MySQL, SQLite, Oracle, and SQL Server drivers. ctx is
+// reserved for implementations that may need to probe the connection in
+// the future and is not currently used.
+func QuoterFromDriver(d driver.Driver, ctx context.Context) (Quoter, error) {
 	if q, is := d.(DriverQuoter); is {
-		return q.Quoter()
+		return q.Quoter(), nil
 	}
-	dv := reflect.ValueOf(d)
-	switch dv.Type().String() {
-	default:
-		return nil
-	case "*mssql.MssqlDriver":
-		return sqlServerQuoter{}
+	typeName := reflect.TypeOf(d).String()
+
+	quoterMu.RLock()
+	q, ok := quoterRegistry[typeName]
+	quoterMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("sqlexp: no Quoter registered for driver type %q", typeName)
+	}
+	return q, nil
+}
+
+// quoteLiteral applies the standard SQL single-quote doubling rule shared
+// by every dialect implemented below.
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// quoteDoubled applies the standard SQL identifier quoting rule (a quote
+// character doubled to escape itself) shared by Postgres, Oracle, and
+// SQLite.
+func quoteDoubled(quote, name string) string {
+	return quote + strings.Replace(name, quote, quote+quote, -1) + quote
+}
+
+// resolveValue unwraps a driver.Valuer, panicking if it fails to produce a
+// value, so that every dialect's Value implementation below can deal in
+// plain Go types.
+func resolveValue(v interface{}) interface{} {
+	if valuer, is := v.(driver.Valuer); is {
+		vv, err := valuer.Value()
+		if err != nil {
+			panic("sqlexp: driver.Valuer.Value failed: " + err.Error())
+		}
+		return vv
+	}
+	return v
+}
+
+// joinQualifiedID quotes each part with q and joins them with dots, which
+// is the multipart identifier separator used by every dialect below.
+func joinQualifiedID(q Quoter, parts ...string) string {
+	ids := make([]string, len(parts))
+	for i, p := range parts {
+		ids[i] = q.ID(p)
+	}
+	return strings.Join(ids, ".")
+}
+
+// numericValue renders the numeric kinds common to every dialect so each
+// Value implementation below only has to special-case the dialect-specific
+// types.
+func numericValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), true
+	case float32, float64:
+		return fmt.Sprintf("%v", v), true
 	}
+	return "", false
 }
 
 type sqlServerQuoter struct{}
@@ -53,11 +153,172 @@ type sqlServerQuoter struct{}
 func (sqlServerQuoter) ID(name string) string {
 	return "[" + strings.Replace(name, "]", "]]", -1) + "]"
 }
+
+func (q sqlServerQuoter) QualifiedID(parts ...string) string {
+	return joinQualifiedID(q, parts...)
+}
+
 func (sqlServerQuoter) Value(v interface{}) string {
+	v = resolveValue(v)
+	if s, ok := numericValue(v); ok {
+		return s
+	}
+	switch v := v.(type) {
+	default:
+		panic(fmt.Sprintf("sqlexp: tsql quoter: unsupported value type %T", v))
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		return "0x" + hex.EncodeToString(v)
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.9999999"))
+	}
+}
+
+type postgresQuoter struct{}
+
+func (postgresQuoter) ID(name string) string {
+	return quoteDoubled(`"`, name)
+}
+
+func (q postgresQuoter) QualifiedID(parts ...string) string {
+	return joinQualifiedID(q, parts...)
+}
+
+func (postgresQuoter) Value(v interface{}) string {
+	v = resolveValue(v)
+	if s, ok := numericValue(v); ok {
+		return s
+	}
+	switch v := v.(type) {
+	default:
+		panic(fmt.Sprintf("sqlexp: postgres quoter: unsupported value type %T", v))
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	case string:
+		// The E'...' form opts into backslash escapes regardless of
+		// the standard_conforming_strings setting, so a literal
+		// backslash is always safe to embed.
+		return "E" + quoteLiteral(strings.Replace(v, `\`, `\\`, -1))
+	case []byte:
+		// Hex format, the bytea_output default since Postgres 9.0.
+		return "E'\\\\x" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999999Z07:00"))
+	}
+}
+
+type mysqlQuoter struct{}
+
+func (mysqlQuoter) ID(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func (q mysqlQuoter) QualifiedID(parts ...string) string {
+	return joinQualifiedID(q, parts...)
+}
+
+func (mysqlQuoter) Value(v interface{}) string {
+	v = resolveValue(v)
+	if s, ok := numericValue(v); ok {
+		return s
+	}
+	switch v := v.(type) {
+	default:
+		panic(fmt.Sprintf("sqlexp: mysql quoter: unsupported value type %T", v))
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		return quoteLiteral(strings.Replace(v, `\`, `\\`, -1))
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999"))
+	}
+}
+
+type sqliteQuoter struct{}
+
+func (sqliteQuoter) ID(name string) string {
+	return quoteDoubled(`"`, name)
+}
+
+func (q sqliteQuoter) QualifiedID(parts ...string) string {
+	return joinQualifiedID(q, parts...)
+}
+
+func (sqliteQuoter) Value(v interface{}) string {
+	v = resolveValue(v)
+	if s, ok := numericValue(v); ok {
+		return s
+	}
+	switch v := v.(type) {
+	default:
+		panic(fmt.Sprintf("sqlexp: sqlite quoter: unsupported value type %T", v))
+	case nil:
+		return "NULL"
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		return quoteLiteral(v)
+	case []byte:
+		return "X'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return quoteLiteral(v.Format("2006-01-02 15:04:05.999999999"))
+	}
+}
+
+type oracleQuoter struct{}
+
+func (oracleQuoter) ID(name string) string {
+	return quoteDoubled(`"`, name)
+}
+
+func (q oracleQuoter) QualifiedID(parts ...string) string {
+	return joinQualifiedID(q, parts...)
+}
+
+func (oracleQuoter) Value(v interface{}) string {
+	v = resolveValue(v)
+	if s, ok := numericValue(v); ok {
+		return s
+	}
 	switch v := v.(type) {
 	default:
-		panic("unsupported value")
+		panic(fmt.Sprintf("sqlexp: oracle quoter: unsupported value type %T", v))
+	case nil:
+		return "NULL"
+	case bool:
+		// Oracle has no native boolean type or literal.
+		if v {
+			return "1"
+		}
+		return "0"
 	case string:
-		return "'" + strings.Replace(v, "'", "''", -1) + "'"
+		return quoteLiteral(v)
+	case []byte:
+		return "'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return "TO_TIMESTAMP(" + quoteLiteral(v.Format("2006-01-02 15:04:05.999999999")) + ", 'YYYY-MM-DD HH24:MI:SS.FF9')"
 	}
 }
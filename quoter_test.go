@@ -26,3 +26,71 @@ func TestMSSQL(t *testing.T) {
 		t.Errorf("quote value failed: got %s wanted %s", qs, wanted)
 	}
 }
+
+func TestPostgresQuoter(t *testing.T) {
+	q := postgresQuoter{}
+
+	if got, wanted := q.ID(`a"b`), `"a""b"`; got != wanted {
+		t.Errorf("ID failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.QualifiedID("public", "users"), `"public"."users"`; got != wanted {
+		t.Errorf("QualifiedID failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value("It's"), `E'It''s'`; got != wanted {
+		t.Errorf("Value string failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value([]byte{0xde, 0xad}), `E'\\xdead'`; got != wanted {
+		t.Errorf("Value []byte failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value(true), "TRUE"; got != wanted {
+		t.Errorf("Value bool failed: got %s wanted %s", got, wanted)
+	}
+}
+
+func TestMySQLQuoter(t *testing.T) {
+	q := mysqlQuoter{}
+
+	if got, wanted := q.ID("a`b"), "`a``b`"; got != wanted {
+		t.Errorf("ID failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value([]byte{0xde, 0xad}), "X'dead'"; got != wanted {
+		t.Errorf("Value []byte failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value(false), "0"; got != wanted {
+		t.Errorf("Value bool failed: got %s wanted %s", got, wanted)
+	}
+}
+
+func TestSQLiteQuoter(t *testing.T) {
+	q := sqliteQuoter{}
+
+	if got, wanted := q.ID(`a"b`), `"a""b"`; got != wanted {
+		t.Errorf("ID failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value(nil), "NULL"; got != wanted {
+		t.Errorf("Value nil failed: got %s wanted %s", got, wanted)
+	}
+}
+
+func TestOracleQuoter(t *testing.T) {
+	q := oracleQuoter{}
+
+	if got, wanted := q.ID(`a"b`), `"a""b"`; got != wanted {
+		t.Errorf("ID failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := q.Value(42), "42"; got != wanted {
+		t.Errorf("Value int failed: got %s wanted %s", got, wanted)
+	}
+}
+
+func TestQuoterFromDriverUnregistered(t *testing.T) {
+	ctx := context.Background()
+	var d driver.Driver = fakeDriver{}
+	if _, err := QuoterFromDriver(d, ctx); err == nil {
+		t.Fatal("expected an error for an unregistered driver type")
+	}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return nil, nil }
@@ -0,0 +1,180 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlexp
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sync"
+)
+
+// Savepointer returns the SQL statements needed to create, release, and
+// roll back to a savepoint within a transaction.
+type Savepointer interface {
+	// Create returns the SQL statement that establishes a savepoint
+	// named name.
+	Create(name string) string
+
+	// Release returns the SQL statement that releases the savepoint
+	// named name, or "" if the dialect has no release verb, in which
+	// case the savepoint simply stays in place until the enclosing
+	// transaction ends.
+	Release(name string) string
+
+	// Rollback returns the SQL statement that rolls back to the
+	// savepoint named name without releasing it.
+	Rollback(name string) string
+}
+
+// DriverSavepointer returns a Savepointer interface and is suitable for
+// extending the driver.Driver type, mirroring DriverQuoter.
+type DriverSavepointer interface {
+	Savepointer() Savepointer
+}
+
+var savepointNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateSavepointName panics if name is not a safe, unquoted SQL
+// identifier. Savepoint names cannot be passed as bind parameters, so
+// this is the only guard against injection if a future API lets callers
+// choose their own name instead of the package-generated "savept%dx".
+func validateSavepointName(name string) {
+	if !savepointNameRe.MatchString(name) {
+		panic(fmt.Sprintf("sqlexp: invalid savepoint name %q", name))
+	}
+}
+
+var (
+	savepointMu sync.RWMutex
+
+	// savepointRegistry maps the reflect type name of a driver.Driver to
+	// the Savepointer for its dialect, seeded with the common drivers
+	// for each built-in dialect so third-party drivers work without
+	// this package importing them.
+	savepointRegistry = map[string]Savepointer{
+		"*mssql.MssqlDriver":    tsqlSavepointer{},
+		"*pq.Driver":            postgresSavepointer{},
+		"*stdlib.Driver":        postgresSavepointer{},
+		"mysql.MySQLDriver":     mysqlSavepointer{},
+		"*sqlite3.SQLiteDriver": sqliteSavepointer{},
+		"*godror.drv":           oracleSavepointer{},
+	}
+)
+
+// RegisterSavepointer registers a Savepointer to be returned by
+// SavepointFromDriver for any driver.Driver whose reflect type name
+// matches driverTypeName, for example "*pgx.Driver".
+func RegisterSavepointer(driverTypeName string, sp Savepointer) {
+	savepointMu.Lock()
+	defer savepointMu.Unlock()
+	savepointRegistry[driverTypeName] = sp
+}
+
+// SavepointFromDriver takes a database driver, often obtained through a
+// sql.DB.Driver call, and returns the Savepointer for its SQL dialect.
+//
+// If the driver implements DriverSavepointer that Savepointer is
+// returned directly. Otherwise the driver's reflect type name is looked
+// up in the registry populated by RegisterSavepointer.
+func SavepointFromDriver(d driver.Driver) (Savepointer, error) {
+	if s, is := d.(DriverSavepointer); is {
+		return s.Savepointer(), nil
+	}
+	typeName := reflect.TypeOf(d).String()
+
+	savepointMu.RLock()
+	sp, ok := savepointRegistry[typeName]
+	savepointMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("sqlexp: no Savepointer registered for driver type %q", typeName)
+	}
+	return sp, nil
+}
+
+type postgresSavepointer struct{}
+
+func (postgresSavepointer) Create(name string) string {
+	validateSavepointName(name)
+	return "SAVEPOINT " + name
+}
+func (postgresSavepointer) Release(name string) string {
+	validateSavepointName(name)
+	return "RELEASE SAVEPOINT " + name
+}
+func (postgresSavepointer) Rollback(name string) string {
+	validateSavepointName(name)
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// mysqlSavepointer uses the same syntax as Postgres, but RELEASE
+// SAVEPOINT semantics differ on some MySQL/MariaDB versions and storage
+// engines: the standard (and Postgres) treat a released savepoint name
+// as unavailable again, while some MySQL versions keep it usable for a
+// following ROLLBACK TO. Verify against the target server version if
+// that distinction matters.
+type mysqlSavepointer struct{}
+
+func (mysqlSavepointer) Create(name string) string {
+	validateSavepointName(name)
+	return "SAVEPOINT " + name
+}
+func (mysqlSavepointer) Release(name string) string {
+	validateSavepointName(name)
+	return "RELEASE SAVEPOINT " + name
+}
+func (mysqlSavepointer) Rollback(name string) string {
+	validateSavepointName(name)
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+type sqliteSavepointer struct{}
+
+func (sqliteSavepointer) Create(name string) string {
+	validateSavepointName(name)
+	return "SAVEPOINT " + name
+}
+func (sqliteSavepointer) Release(name string) string {
+	validateSavepointName(name)
+	return "RELEASE " + name
+}
+func (sqliteSavepointer) Rollback(name string) string {
+	validateSavepointName(name)
+	return "ROLLBACK TO " + name
+}
+
+// oracleSavepointer has no release verb: an Oracle savepoint is
+// automatically released when the enclosing transaction ends, so
+// Release returns "" as nest.Tx.Commit already anticipates.
+type oracleSavepointer struct{}
+
+func (oracleSavepointer) Create(name string) string {
+	validateSavepointName(name)
+	return "SAVEPOINT " + name
+}
+func (oracleSavepointer) Release(name string) string {
+	return ""
+}
+func (oracleSavepointer) Rollback(name string) string {
+	validateSavepointName(name)
+	return "ROLLBACK TO " + name
+}
+
+// tsqlSavepointer also has no release verb; a SAVE TRANSACTION mark is
+// simply discarded along with the rest of the transaction.
+type tsqlSavepointer struct{}
+
+func (tsqlSavepointer) Create(name string) string {
+	validateSavepointName(name)
+	return "SAVE TRANSACTION " + name
+}
+func (tsqlSavepointer) Release(name string) string {
+	return ""
+}
+func (tsqlSavepointer) Rollback(name string) string {
+	validateSavepointName(name)
+	return "ROLLBACK TRANSACTION " + name
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sqlexp
+
+import "testing"
+
+func TestPostgresSavepointer(t *testing.T) {
+	sp := postgresSavepointer{}
+	if got, wanted := sp.Create("savept1x"), "SAVEPOINT savept1x"; got != wanted {
+		t.Errorf("Create failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := sp.Release("savept1x"), "RELEASE SAVEPOINT savept1x"; got != wanted {
+		t.Errorf("Release failed: got %s wanted %s", got, wanted)
+	}
+	if got, wanted := sp.Rollback("savept1x"), "ROLLBACK TO SAVEPOINT savept1x"; got != wanted {
+		t.Errorf("Rollback failed: got %s wanted %s", got, wanted)
+	}
+}
+
+func TestOracleSavepointerNoRelease(t *testing.T) {
+	sp := oracleSavepointer{}
+	if got := sp.Release("savept1x"); got != "" {
+		t.Errorf("Release failed: got %q wanted \"\"", got)
+	}
+}
+
+func TestTSQLSavepointer(t *testing.T) {
+	sp := tsqlSavepointer{}
+	if got, wanted := sp.Create("savept1x"), "SAVE TRANSACTION savept1x"; got != wanted {
+		t.Errorf("Create failed: got %s wanted %s", got, wanted)
+	}
+	if got := sp.Release("savept1x"); got != "" {
+		t.Errorf("Release failed: got %q wanted \"\"", got)
+	}
+}
+
+func TestValidateSavepointNamePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for an invalid savepoint name")
+		}
+	}()
+	postgresSavepointer{}.Create("bad; name")
+}
+
+func TestSavepointFromDriverUnregistered(t *testing.T) {
+	if _, err := SavepointFromDriver(fakeDriver{}); err == nil {
+		t.Fatal("expected an error for an unregistered driver type")
+	}
+}
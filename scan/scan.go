@@ -0,0 +1,259 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package scan maps SQL rows onto Go structs by column name, over the
+// nest.Querier interface so it works uniformly across a nest.DB,
+// nest.Tx, or nest.Conn (including nested savepoint transactions).
+package scan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-sql/sqlexp/nest"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// isLeaf reports whether a struct field's type should be scanned into
+// directly rather than, if anonymous, flattened into its parent.
+func isLeaf(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	if t.Implements(scannerType) {
+		return true
+	}
+	if reflect.PtrTo(t).Implements(scannerType) {
+		return true
+	}
+	return false
+}
+
+// field locates a scan target within a (possibly nested, via embedded
+// structs) destination struct.
+type field struct {
+	index []int
+}
+
+type structInfo struct {
+	// columns maps a lower-cased column name to the field it scans
+	// into.
+	columns map[string]field
+}
+
+var structInfoCache sync.Map // reflect.Type -> *structInfo
+
+func structInfoFor(t reflect.Type) *structInfo {
+	if v, ok := structInfoCache.Load(t); ok {
+		return v.(*structInfo)
+	}
+	info := &structInfo{columns: map[string]field{}}
+	collectFields(t, nil, info)
+	// Races on first use build the same info twice; LoadOrStore keeps
+	// whichever copy won without requiring a lock around collectFields.
+	actual, _ := structInfoCache.LoadOrStore(t, info)
+	return actual.(*structInfo)
+}
+
+func collectFields(t reflect.Type, index []int, info *structInfo) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // unexported
+		}
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && ft.Kind() == reflect.Struct && !isLeaf(ft) {
+			collectFields(ft, idx, info)
+			continue
+		}
+
+		name := sf.Tag.Get("db")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = toSnakeCase(sf.Name)
+		}
+		info.columns[strings.ToLower(name)] = field{index: idx}
+	}
+}
+
+// toSnakeCase lower-cases s and underscores word boundaries, treating a
+// run of consecutive capitals as a single acronym rather than one word
+// per letter, so "ID" becomes "id" and "UserID" becomes "user_id" (not
+// "i_d"/"user_i_d"). A boundary is only inserted before a capital that
+// starts a new word other than the first character of the string: one
+// preceded by a lowercase letter (fooBar -> foo_bar), or the last capital
+// of an acronym run immediately followed by a lowercase letter
+// (HTTPServer -> http_server).
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if r >= 'A' && r <= 'Z' {
+			startsWord := i > 0 && (isLower(runes[i-1]) || (i+1 < len(runes) && isLower(runes[i+1])))
+			if startsWord {
+				b.WriteByte('_')
+			}
+			b.WriteByte(byte(r - 'A' + 'a'))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isLower(r rune) bool {
+	return r >= 'a' && r <= 'z'
+}
+
+// plan is the per-(struct type, column list) precomputed mapping from a
+// row's columns to destination struct fields, so repeated queries with
+// the same shape do not repeat the column-name lookup on every row.
+type plan struct {
+	// fields[i] is the field scanned into for columns[i], or nil if the
+	// column has no matching field and should be discarded.
+	fields []*field
+}
+
+var planCache sync.Map // planCacheKey -> *plan
+
+type planCacheKey struct {
+	typ     reflect.Type
+	columns string
+}
+
+func planFor(t reflect.Type, columns []string) *plan {
+	key := planCacheKey{typ: t, columns: strings.Join(columns, "\x00")}
+	if v, ok := planCache.Load(key); ok {
+		return v.(*plan)
+	}
+
+	info := structInfoFor(t)
+	p := &plan{fields: make([]*field, len(columns))}
+	for i, col := range columns {
+		if f, ok := info.columns[strings.ToLower(col)]; ok {
+			f := f
+			p.fields[i] = &f
+		}
+	}
+	actual, _ := planCache.LoadOrStore(key, p)
+	return actual.(*plan)
+}
+
+// fieldByIndex walks dest along index the way reflect.Value.FieldByIndex
+// does, except that it allocates a new zero value for any nil embedded
+// pointer it passes through instead of panicking. A freshly
+// reflect.New'd struct leaves embedded *struct fields nil, and
+// collectFields happily recurses into those, so without this a pointer
+// embed with no matching column ever allocated would panic on the very
+// first scan.
+func fieldByIndex(dest reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			if dest.Kind() == reflect.Ptr {
+				if dest.IsNil() {
+					dest.Set(reflect.New(dest.Type().Elem()))
+				}
+				dest = dest.Elem()
+			}
+		}
+		dest = dest.Field(x)
+	}
+	return dest
+}
+
+// scanTargets builds the []interface{} to pass to rows.Scan for a single
+// row of dest (a reflect.Value addressing a struct), following p.
+func scanTargets(dest reflect.Value, p *plan) []interface{} {
+	targets := make([]interface{}, len(p.fields))
+	for i, f := range p.fields {
+		if f == nil {
+			var discard interface{}
+			targets[i] = &discard
+			continue
+		}
+		targets[i] = fieldByIndex(dest, f.index).Addr().Interface()
+	}
+	return targets
+}
+
+// ScanStruct scans the current row of rows into dest, a pointer to a
+// struct. Columns are matched to fields by their "db" struct tag,
+// falling back to the snake_case of the field name; embedded structs
+// (other than time.Time and sql.Scanner implementations) are flattened
+// into their parent.
+func ScanStruct(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("scan: ScanStruct needs a non-nil pointer to a struct, got %T", dest)
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	p := planFor(v.Elem().Type(), columns)
+	return rows.Scan(scanTargets(v.Elem(), p)...)
+}
+
+// SelectSlice runs query against q and appends the results to *dest, a
+// pointer to a slice of struct or *struct, using the same column mapping
+// as ScanStruct.
+func SelectSlice(ctx context.Context, q nest.Querier, dest interface{}, query string, args ...interface{}) error {
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() || dv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("scan: SelectSlice needs a non-nil pointer to a slice, got %T", dest)
+	}
+	slice := dv.Elem()
+	elemType := slice.Type().Elem()
+	ptrElem := elemType.Kind() == reflect.Ptr
+	structType := elemType
+	if ptrElem {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return fmt.Errorf("scan: SelectSlice needs a pointer to a slice of structs, got %T", dest)
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	p := planFor(structType, columns)
+
+	for rows.Next() {
+		elem := reflect.New(structType)
+		if err := rows.Scan(scanTargets(elem.Elem(), p)...); err != nil {
+			return err
+		}
+		if ptrElem {
+			slice.Set(reflect.Append(slice, elem))
+		} else {
+			slice.Set(reflect.Append(slice, elem.Elem()))
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package scan
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"Name":       "name",
+		"FirstName":  "first_name",
+		"ID":         "id",
+		"UserID":     "user_id",
+		"HTTPServer": "http_server",
+	}
+	for in, want := range cases {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type Address struct {
+	City string `db:"city"`
+}
+
+type User struct {
+	Address
+	Name      string `db:"name"`
+	Email     string
+	CreatedAt sql.NullString `db:"created_at"`
+	hidden    string
+}
+
+func TestStructInfoFlattensEmbeddedStructs(t *testing.T) {
+	info := structInfoFor(reflect.TypeOf(User{}))
+
+	for _, col := range []string{"city", "name", "email", "created_at"} {
+		if _, ok := info.columns[col]; !ok {
+			t.Errorf("expected column %q to be mapped", col)
+		}
+	}
+	if _, ok := info.columns["hidden"]; ok {
+		t.Error("unexported field must not be mapped")
+	}
+}
+
+func TestPlanForDiscardsUnknownColumns(t *testing.T) {
+	p := planFor(reflect.TypeOf(User{}), []string{"name", "extra", "city"})
+	if len(p.fields) != 3 {
+		t.Fatalf("got %d fields, want 3", len(p.fields))
+	}
+	if p.fields[0] == nil || p.fields[2] == nil {
+		t.Error("expected name and city to resolve to fields")
+	}
+	if p.fields[1] != nil {
+		t.Error("expected extra to be discarded")
+	}
+}
+
+type Profile struct {
+	*Address
+	Bio string `db:"bio"`
+}
+
+func TestScanTargetsAllocatesNilEmbeddedPointer(t *testing.T) {
+	p := planFor(reflect.TypeOf(Profile{}), []string{"bio", "city"})
+
+	// reflect.New leaves Profile.Address nil, exactly like scanning into
+	// a freshly allocated struct would; this is the case scanTargets
+	// must handle without panicking.
+	dest := reflect.New(reflect.TypeOf(Profile{})).Elem()
+	if !dest.Field(0).IsNil() {
+		t.Fatal("expected a fresh Profile's embedded *Address to start nil")
+	}
+
+	targets := scanTargets(dest, p)
+	*(targets[0].(*string)) = "hello"
+	*(targets[1].(*string)) = "NYC"
+
+	if dest.Field(0).IsNil() {
+		t.Fatal("expected scanTargets to allocate the embedded *Address")
+	}
+	if got := dest.Interface().(Profile); got.Bio != "hello" || got.City != "NYC" {
+		t.Errorf("got %+v", got)
+	}
+}